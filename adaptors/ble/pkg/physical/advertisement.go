@@ -0,0 +1,36 @@
+package physical
+
+import (
+	"encoding/binary"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+	"github.com/rancher/octopus/adaptors/ble/pkg/physical/transport"
+)
+
+// updateAdvertisement records a's RSSI and payload on status, so a
+// controller reacting to signal strength or a vendor broadcast payload
+// (e.g. iBeacon/Eddystone) doesn't need to open a GATT connection at all.
+func (c *Controller) updateAdvertisement(a transport.Advertisement) {
+	c.status.RSSI = a.RSSI
+	c.status.TxPowerLevel = a.TxPowerLevel
+
+	c.status.ServiceUUIDs = a.ServiceUUIDs
+
+	c.status.ServiceData = nil
+	for _, sd := range a.ServiceData {
+		c.status.ServiceData = append(c.status.ServiceData, v1alpha1.ServiceDataElement{
+			UUID: sd.UUID,
+			Data: sd.Data,
+		})
+	}
+
+	// the manufacturer-specific payload is a company ID (little-endian
+	// uint16) followed by an opaque vendor payload
+	c.status.Manufacturer = nil
+	if len(a.ManufacturerData) >= 2 {
+		c.status.Manufacturer = append(c.status.Manufacturer, v1alpha1.ManufacturerData{
+			CompanyID: binary.LittleEndian.Uint16(a.ManufacturerData[:2]),
+			Payload:   a.ManufacturerData[2:],
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package physical
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+)
+
+// DataCodec decodes raw characteristic bytes into the string value reported
+// on status, and encodes a string value (e.g. PropertyVisitor.DefaultValue)
+// back into bytes for a write, replacing the old single ConvertReadData/
+// DataWriteTo pairing with something that understands packed, typed GATT
+// payloads (little/big-endian integers, floats, BLE SFLOATs, CBOR).
+type DataCodec interface {
+	Decode(b []byte) (string, error)
+	Encode(value string) ([]byte, error)
+}
+
+// newCodec resolves the DataCodec named by visitor.DataCodec and, if a Scale
+// or Offset is configured, wraps it to rescale the decoded/encoded value.
+// Scale/Offset is the numeric post-decode transform; it is independent of
+// ByteOffset/Length, the byte-slice window windowBytes carves out before
+// decoding ever runs.
+func newCodec(visitor v1alpha1.PropertyVisitor) (DataCodec, error) {
+	base, err := dataCodecByName(visitor.DataCodec)
+	if err != nil {
+		return nil, err
+	}
+	if visitor.Scale != 0 || visitor.Offset != 0 {
+		return scaledCodec{inner: base, scale: visitor.Scale, offset: visitor.Offset}, nil
+	}
+	return base, nil
+}
+
+func dataCodecByName(name string) (DataCodec, error) {
+	switch name {
+	case "", "raw-hex":
+		return rawHexCodec{}, nil
+	case "utf8":
+		return utf8Codec{}, nil
+	case "uint16-le":
+		return intCodec{size: 2, order: binary.LittleEndian}, nil
+	case "uint16-be":
+		return intCodec{size: 2, order: binary.BigEndian}, nil
+	case "uint32-le":
+		return intCodec{size: 4, order: binary.LittleEndian}, nil
+	case "uint32-be":
+		return intCodec{size: 4, order: binary.BigEndian}, nil
+	case "int16-le":
+		return intCodec{size: 2, order: binary.LittleEndian, signed: true}, nil
+	case "int32-le":
+		return intCodec{size: 4, order: binary.LittleEndian, signed: true}, nil
+	case "float32-le":
+		return float32Codec{order: binary.LittleEndian}, nil
+	case "float32-be":
+		return float32Codec{order: binary.BigEndian}, nil
+	case "sfloat":
+		return sfloatCodec{}, nil
+	case "cbor":
+		return cborCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown data codec %q", name)
+	}
+}
+
+// windowBytes slices b to the byte window described by visitor's ByteOffset
+// and Length, so a single characteristic carrying a packed struct can be
+// split across several DeviceProperty entries. A zero Length means "to the
+// end". ByteOffset is independent of Scale/Offset, the numeric post-decode
+// transform applied by newCodec.
+func windowBytes(b []byte, visitor v1alpha1.PropertyVisitor) []byte {
+	var start = visitor.ByteOffset
+	if start <= 0 {
+		start = 0
+	}
+	if start >= len(b) {
+		return nil
+	}
+	if visitor.Length <= 0 {
+		return b[start:]
+	}
+	var end = start + visitor.Length
+	if end > len(b) {
+		end = len(b)
+	}
+	return b[start:end]
+}
+
+type rawHexCodec struct{}
+
+func (rawHexCodec) Decode(b []byte) (string, error) { return hex.EncodeToString(b), nil }
+func (rawHexCodec) Encode(value string) ([]byte, error) { return hex.DecodeString(value) }
+
+type utf8Codec struct{}
+
+func (utf8Codec) Decode(b []byte) (string, error)     { return string(b), nil }
+func (utf8Codec) Encode(value string) ([]byte, error) { return []byte(value), nil }
+
+// intCodec decodes/encodes a fixed-width little/big-endian integer.
+type intCodec struct {
+	size   int
+	order  binary.ByteOrder
+	signed bool
+}
+
+func (c intCodec) Decode(b []byte) (string, error) {
+	if len(b) < c.size {
+		return "", fmt.Errorf("expected at least %d bytes, got %d", c.size, len(b))
+	}
+	if c.signed {
+		return strconv.FormatInt(c.signedValue(b), 10), nil
+	}
+	return strconv.FormatUint(c.unsignedValue(b), 10), nil
+}
+
+func (c intCodec) Encode(value string) ([]byte, error) {
+	var b = make([]byte, c.size)
+	if c.signed {
+		n, err := strconv.ParseInt(value, 10, c.size*8)
+		if err != nil {
+			return nil, err
+		}
+		switch c.size {
+		case 2:
+			c.order.PutUint16(b, uint16(int16(n)))
+		case 4:
+			c.order.PutUint32(b, uint32(int32(n)))
+		}
+		return b, nil
+	}
+	n, err := strconv.ParseUint(value, 10, c.size*8)
+	if err != nil {
+		return nil, err
+	}
+	switch c.size {
+	case 2:
+		c.order.PutUint16(b, uint16(n))
+	case 4:
+		c.order.PutUint32(b, uint32(n))
+	}
+	return b, nil
+}
+
+func (c intCodec) unsignedValue(b []byte) uint64 {
+	switch c.size {
+	case 2:
+		return uint64(c.order.Uint16(b))
+	default:
+		return uint64(c.order.Uint32(b))
+	}
+}
+
+func (c intCodec) signedValue(b []byte) int64 {
+	switch c.size {
+	case 2:
+		return int64(int16(c.order.Uint16(b)))
+	default:
+		return int64(int32(c.order.Uint32(b)))
+	}
+}
+
+// float32Codec decodes/encodes an IEEE 754 single-precision float.
+type float32Codec struct{ order binary.ByteOrder }
+
+func (c float32Codec) Decode(b []byte) (string, error) {
+	if len(b) < 4 {
+		return "", fmt.Errorf("expected at least 4 bytes, got %d", len(b))
+	}
+	var f = math.Float32frombits(c.order.Uint32(b))
+	return strconv.FormatFloat(float64(f), 'f', -1, 32), nil
+}
+
+func (c float32Codec) Encode(value string) ([]byte, error) {
+	f, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return nil, err
+	}
+	var b = make([]byte, 4)
+	c.order.PutUint32(b, math.Float32bits(float32(f)))
+	return b, nil
+}
+
+// sfloatCodec decodes the IEEE-11073 16-bit SFLOAT used by BLE health
+// profiles (e.g. Health Thermometer, Glucose): a little-endian 4-bit
+// exponent and 12-bit signed mantissa, value = mantissa * 10^exponent.
+type sfloatCodec struct{}
+
+func (sfloatCodec) Decode(b []byte) (string, error) {
+	if len(b) < 2 {
+		return "", fmt.Errorf("expected at least 2 bytes, got %d", len(b))
+	}
+	var raw = binary.LittleEndian.Uint16(b)
+	var mantissa = int16(raw<<4) >> 4
+	var exponent = int8(raw >> 12)
+	if exponent > 7 {
+		exponent -= 16
+	}
+	var value = float64(mantissa) * math.Pow(10, float64(exponent))
+	return strconv.FormatFloat(value, 'f', -1, 64), nil
+}
+
+func (sfloatCodec) Encode(string) ([]byte, error) {
+	return nil, fmt.Errorf("sfloat encoding is not supported")
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Decode(b []byte) (string, error) {
+	var v interface{}
+	if err := cbor.Unmarshal(b, &v); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+func (cborCodec) Encode(value string) ([]byte, error) {
+	return cbor.Marshal(value)
+}
+
+// scaledCodec applies value*Scale+Offset (and its inverse on encode) around
+// a numeric base codec. Non-numeric codecs (raw-hex, utf8, cbor) pass
+// through untouched.
+type scaledCodec struct {
+	inner  DataCodec
+	scale  float64
+	offset float64
+}
+
+func (c scaledCodec) passthrough() bool {
+	switch c.inner.(type) {
+	case rawHexCodec, utf8Codec, cborCodec:
+		return true
+	}
+	return false
+}
+
+func (c scaledCodec) Decode(b []byte) (string, error) {
+	var raw, err = c.inner.Decode(b)
+	if err != nil || c.passthrough() {
+		return raw, err
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return raw, nil
+	}
+	var scale = c.scale
+	if scale == 0 {
+		scale = 1
+	}
+	return strconv.FormatFloat(f*scale+c.offset, 'f', -1, 64), nil
+}
+
+func (c scaledCodec) Encode(value string) ([]byte, error) {
+	if c.passthrough() {
+		return c.inner.Encode(value)
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return c.inner.Encode(value)
+	}
+	var scale = c.scale
+	if scale == 0 {
+		scale = 1
+	}
+	return c.inner.Encode(strconv.FormatFloat((f-c.offset)/scale, 'f', -1, 64))
+}
@@ -0,0 +1,75 @@
+package physical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+)
+
+func TestIntCodecRoundTrip(t *testing.T) {
+	var codec = intCodec{size: 2, order: binary.LittleEndian}
+	b, err := codec.Encode("513")
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	value, err := codec.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if value != "513" {
+		t.Errorf("Decode(Encode(513)) = %q, want %q", value, "513")
+	}
+}
+
+func TestScaledCodec(t *testing.T) {
+	var codec = scaledCodec{inner: intCodec{size: 2, order: binary.LittleEndian}, scale: 0.1}
+	var b = make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, 205)
+
+	value, err := codec.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if value != "20.5" {
+		t.Errorf("Decode() = %q, want %q", value, "20.5")
+	}
+}
+
+// TestNewCodecByteOffsetDoesNotScale guards against ByteOffset (the window
+// into a packed struct) being mistaken for Offset (the numeric post-decode
+// transform) and silently wrapping the result in a scaledCodec.
+func TestNewCodecByteOffsetDoesNotScale(t *testing.T) {
+	var codec, err = newCodec(v1alpha1.PropertyVisitor{DataCodec: "uint16-le", ByteOffset: 2})
+	if err != nil {
+		t.Fatalf("newCodec() error = %v", err)
+	}
+	if _, ok := codec.(scaledCodec); ok {
+		t.Errorf("newCodec() wrapped a ByteOffset-only visitor in scaledCodec")
+	}
+}
+
+func TestWindowBytes(t *testing.T) {
+	var payload = []byte{0x01, 0x02, 0x03, 0x04}
+	var cases = []struct {
+		name   string
+		offset int
+		length int
+		want   []byte
+	}{
+		{"no window", 0, 0, payload},
+		{"offset only", 2, 0, payload[2:]},
+		{"offset and length", 1, 2, payload[1:3]},
+		{"offset past end", 10, 0, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var visitor = v1alpha1.PropertyVisitor{ByteOffset: c.offset, Length: c.length}
+			if got := windowBytes(payload, visitor); !bytes.Equal(got, c.want) {
+				t.Errorf("windowBytes() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
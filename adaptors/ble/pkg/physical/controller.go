@@ -2,13 +2,13 @@ package physical
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/bettercap/gatt"
 	"github.com/go-logr/logr"
-	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+	"github.com/rancher/octopus/adaptors/ble/pkg/physical/transport"
 )
 
 type Controller struct {
@@ -16,103 +16,132 @@ type Controller struct {
 	status v1alpha1.BluetoothDeviceStatus
 	done   chan struct{}
 	log    logr.Logger
-}
 
-func (c *Controller) onStateChanged(d gatt.Device, s gatt.State) {
-	c.log.Info("Bluetooth state", s)
-	switch s {
-	case gatt.StatePoweredOn:
-		c.log.Info("Scanning...")
-		d.Scan([]gatt.UUID{}, false)
-		return
-	default:
-		d.StopScanning()
-	}
-}
+	// stop is closed when the owning Pool entry is deregistered, telling
+	// onPeripheralConnected to end its persistent session and disconnect.
+	stop <-chan struct{}
 
-func (c *Controller) onPeripheralDiscovered(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
-	name := c.spec.Protocol.Name
-	addr := c.spec.Protocol.MacAddress
-	if name != "" && a.LocalName != name {
-		return
-	}
+	// reconnecting is true once this Controller has already connected at
+	// least once, so a subsequent onPeripheralConnected call knows it is
+	// re-establishing a session rather than starting a fresh one.
+	reconnecting bool
+	// syncDue forces a re-read of ReadOnly properties on the next connect,
+	// even while reconnecting, because a sync interval tick is due.
+	syncDue bool
+	// notifiers keeps the notify callback for each NotifyOnly characteristic
+	// UUID, so the same subscription can be re-armed after a reconnect
+	// without losing its handler.
+	notifiers map[string]func(transport.Characteristic, []byte, error)
+}
 
-	if addr != "" && strings.ToUpper(p.ID()) != strings.ToUpper(addr) {
-		return
+// onPeripheralDiscovered reports whether p matches this Controller's spec
+// and, if so, records its advertisement. The caller owns scanning and
+// connection state, since a shared adapter may be driving other
+// Controllers too.
+func (c *Controller) onPeripheralDiscovered(p transport.Peripheral, a transport.Advertisement) bool {
+	if !matchDiscovery(c.spec, p, a) {
+		return false
 	}
 
-	// Stop scanning once we've got the peripheral we're looking for.
-	c.log.Info("Stop scanning and found device", a.LocalName)
-	p.Device().StopScanning()
+	c.log.Info("Found device", a.LocalName)
 	c.log.Info("Peripheral ID, name", p.ID(), p.Name())
-	p.Device().Connect(p)
+	c.updateAdvertisement(a)
+
+	if c.spec.Protocol.ScanOnly {
+		c.log.Info("ScanOnly device, recording advertisement without connecting")
+		if c.done != nil {
+			close(c.done)
+		}
+	}
+	return true
 }
 
-func (c *Controller) onPeripheralConnected(p gatt.Peripheral, err error) {
+func (c *Controller) onPeripheralConnected(p transport.Peripheral, err error) {
 	c.log.Info("Connected to", p.Name())
-	defer p.Device().CancelConnection(p)
+	defer func() { c.reconnecting = true }()
+
+	if c.notifiers == nil {
+		c.notifiers = make(map[string]func(transport.Characteristic, []byte, error))
+	}
 
-	if err := p.SetMTU(500); err != nil {
-		c.log.Error(err, "Failed to set MTU")
+	// refresh RSSI now that we have a live connection, since it is usually
+	// stronger/more stable a reading than the last advertisement's. Some
+	// backends (e.g. tinygodriver) don't support a live read and report 0;
+	// don't let that clobber the advertisement's RSSI. This only samples
+	// once at connect — periodic refresh while connected isn't implemented.
+	if rssi := p.ReadRSSI(); rssi != 0 {
+		c.status.RSSI = rssi
 	}
 
-	// Discovery services
-	ss, err := p.DiscoverServices(nil)
+	chars, err := p.DiscoverCharacteristics()
 	if err != nil {
-		c.log.Error(err, "Failed to discover services")
+		c.log.Error(err, "Failed to discover characteristics")
+		c.disconnect(p)
 		return
 	}
 
-	for _, svc := range ss {
-
-		// Discovery characteristics
-		cs, err := p.DiscoverCharacteristics(nil, svc)
-		if err != nil {
-			c.log.Error(err, "Failed to discover characteristics")
+	for _, ch := range chars {
+		property, found := findCharacteristic(c.spec, ch.UUID())
+		if !found {
 			continue
 		}
 
-		for _, ch := range cs {
-			property, found := findCharacteristic(c.spec, svc.UUID().String())
-			if !found {
-				continue
-			}
-
-			switch property.AccessMode {
-			case v1alpha1.ReadOnly:
-				{
-					_, err := c.readCharacteristic(p, ch, property)
-					if err != nil {
-						c.log.Error(err, "Failed to read Characteristic")
-						continue
-					}
+		switch property.AccessMode {
+		case v1alpha1.ReadOnly:
+			{
+				if c.reconnecting && !c.syncDue {
+					continue
 				}
-			case v1alpha1.ReadWrite:
-				{
-					err := c.writeCharacteristic(p, ch, property)
-					if err != nil {
-						c.log.Error(err, "Failed to write Characteristic")
-						return
-					}
+				_, err := c.readCharacteristic(p, ch, property)
+				if err != nil {
+					c.log.Error(err, "Failed to read Characteristic")
+					continue
 				}
-			case v1alpha1.NotifyOnly:
-				{
-					err := c.getNotifyCharacteristic(p, ch, property)
-					if err != nil {
-						c.log.Error(err, "Failed to get notify Characteristic")
-						return
-					}
+			}
+		case v1alpha1.ReadWrite:
+			{
+				err := c.writeCharacteristic(p, ch, property)
+				if err != nil {
+					c.log.Error(err, "Failed to write Characteristic")
+					c.disconnect(p)
+					return
+				}
+			}
+		case v1alpha1.NotifyOnly:
+			{
+				err := c.getNotifyCharacteristic(p, ch, property)
+				if err != nil {
+					c.log.Error(err, "Failed to get notify Characteristic")
+					c.disconnect(p)
+					return
 				}
-			default:
-				c.log.Info("AccessMode is not defined or either not a valid option", property.AccessMode)
 			}
+		default:
+			c.log.Info("AccessMode is not defined or either not a valid option", property.AccessMode)
 		}
 	}
-	c.log.Info("Waiting for 5 seconds to get some notifications, if any.")
-	time.Sleep(5 * time.Second)
+
+	// hold the session open instead of disconnecting, so NotifyOnly
+	// subscriptions keep delivering until this Controller is deregistered or
+	// the peripheral drops the link on its own (e.g. a wearable sleeping)
+	c.log.Info("Session established, holding connection open")
+	select {
+	case <-c.stop:
+		c.disconnect(p)
+	case <-c.done:
+		// already disconnected; nothing left to tear down
+	}
+}
+
+// disconnect tears down p, logging rather than surfacing the error since
+// the caller has nothing further to do with it.
+func (c *Controller) disconnect(p transport.Peripheral) {
+	if err := p.Disconnect(); err != nil {
+		c.log.Error(err, "Failed to disconnect")
+	}
 }
 
-func (c *Controller) onPeriphDisconnected(p gatt.Peripheral, err error) {
+func (c *Controller) onPeriphDisconnected(p transport.Peripheral, err error) {
 	c.log.Info("Device disconnected")
 	if c.done != nil {
 		close(c.done)
@@ -129,30 +158,37 @@ func findCharacteristic(spec v1alpha1.BluetoothDeviceSpec, characteristicUUID st
 	return deviceProperty, false
 }
 
-func (c *Controller) readCharacteristic(p gatt.Peripheral, ch *gatt.Characteristic, property v1alpha1.DeviceProperty) (string, error) {
+func (c *Controller) readCharacteristic(p transport.Peripheral, ch transport.Characteristic, property v1alpha1.DeviceProperty) (string, error) {
 	b, err := p.ReadCharacteristic(ch)
 	if err != nil {
 		return "", err
 	}
 	c.log.Info(fmt.Sprintf("ReadCharacteristic value %x | %q\n", b, b))
 
-	convertedValue := fmt.Sprintf("%f", ConvertReadData(property.Visitor.BluetoothDataConverter, b))
+	codec, err := newCodec(property.Visitor)
+	if err != nil {
+		return "", err
+	}
+	convertedValue, err := codec.Decode(windowBytes(b, property.Visitor))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode characteristic value: %s", err.Error())
+	}
 	c.log.Info("Converted read value to", convertedValue)
 	c.updateDeviceStatus(property.Name, "", convertedValue)
 	return convertedValue, nil
 }
 
-func (c *Controller) writeCharacteristic(p gatt.Peripheral, ch *gatt.Characteristic, property v1alpha1.DeviceProperty) error {
-	if len(property.Visitor.DataWriteTo) == 0 {
-		return fmt.Errorf("invalid length 0 of writeDataTo")
+func (c *Controller) writeCharacteristic(p transport.Peripheral, ch transport.Characteristic, property v1alpha1.DeviceProperty) error {
+	codec, err := newCodec(property.Visitor)
+	if err != nil {
+		return err
 	}
-
-	byteData, hasValue := findDataWriteToDeviceByDefaultValue(property.Visitor)
-	if !hasValue {
-		return fmt.Errorf("invalid length 0 of writeData")
+	byteData, err := codec.Encode(property.Visitor.DefaultValue)
+	if err != nil {
+		return fmt.Errorf("failed to encode default value %q: %s", property.Visitor.DefaultValue, err.Error())
 	}
 
-	err := p.WriteCharacteristic(ch, byteData, true)
+	err = p.WriteCharacteristic(ch, byteData, true)
 	if err != nil {
 		return fmt.Errorf("failed to write characteristic: %s with error: %s", ch.UUID(), err.Error())
 	}
@@ -165,51 +201,73 @@ func (c *Controller) writeCharacteristic(p gatt.Peripheral, ch *gatt.Characteris
 	return nil
 }
 
-func (c *Controller) getNotifyCharacteristic(p gatt.Peripheral, ch *gatt.Characteristic, property v1alpha1.DeviceProperty) error {
-	_, err := p.DiscoverDescriptors(nil, ch)
-	if err != nil {
-		return fmt.Errorf("failed to discover descriptors, %s", err.Error())
+func (c *Controller) getNotifyCharacteristic(p transport.Peripheral, ch transport.Characteristic, property v1alpha1.DeviceProperty) error {
+	if !ch.Notifiable() {
+		return nil
 	}
 
-	// Subscribe the characteristic, if possible.
-	if (ch.Properties() & (gatt.CharNotify | gatt.CharIndicate)) != 0 {
-		f := func(ch *gatt.Characteristic, b []byte, err error) {
+	// Subscribe the characteristic. The notifier is kept on the Controller so
+	// a later reconnect re-arms the same subscription instead of dropping it.
+	uuid := ch.UUID()
+	notifier, known := c.notifiers[uuid]
+	if !known {
+		codec, err := newCodec(property.Visitor)
+		if err != nil {
+			return fmt.Errorf("failed to resolve codec for characteristic %s: %s", uuid, err.Error())
+		}
+		notifier = func(ch transport.Characteristic, b []byte, err error) {
 			c.log.Info(fmt.Sprintf("notified: % X | %q\n", b, b))
-			value := fmt.Sprintf("%q", b)
+			value, decodeErr := codec.Decode(windowBytes(b, property.Visitor))
+			if decodeErr != nil {
+				c.log.Error(decodeErr, "Failed to decode notified value")
+				return
+			}
 			c.updateDeviceStatus(property.Name, "", value)
 		}
-		if err := p.SetNotifyValue(ch, f); err != nil {
-			return fmt.Errorf("failed to subscribe characteristic, %s", err.Error())
-		}
+		c.notifiers[uuid] = notifier
+	}
+	if err := p.SetNotifyValue(ch, notifier); err != nil {
+		return fmt.Errorf("failed to subscribe characteristic, %s", err.Error())
 	}
 	return nil
 }
 
-func findDataWriteToDeviceByDefaultValue(visitor v1alpha1.PropertyVisitor) ([]byte, bool) {
-	for k, v := range visitor.DataWriteTo {
-		if visitor.DefaultValue == k {
-			return v, true
-		}
-	}
-	return nil, false
+func (c *Controller) updateDeviceStatus(name, desired, reported string) {
+	upsertStatusProperty(&c.status, name, desired, reported)
 }
 
-func (c *Controller) updateDeviceStatus(name, desired, reported string) {
+// upsertStatusProperty records name's desired/reported value on status,
+// replacing any existing entry. Shared by the central Controller and the
+// peripheral PeripheralServer so both report status the same way.
+func upsertStatusProperty(status *v1alpha1.BluetoothDeviceStatus, name, desired, reported string) {
 	sp := v1alpha1.StatusProperties{
 		Name:      name,
 		Desired:   desired,
 		Reported:  reported,
 		UpdatedAt: metav1.Time{Time: time.Now()},
 	}
-	found := false
-	for i, property := range c.status.Properties {
+	for i, property := range status.Properties {
 		if property.Name == sp.Name {
-			c.status.Properties[i] = sp
-			found = true
-			break
+			status.Properties[i] = sp
+			return
 		}
 	}
-	if !found {
-		c.status.Properties = append(c.status.Properties, sp)
+	status.Properties = append(status.Properties, sp)
+}
+
+// statusPropertyValue returns name's last reported value (falling back to
+// its desired value, then fallback), for serving a read in peripheral mode.
+func statusPropertyValue(status v1alpha1.BluetoothDeviceStatus, name, fallback string) string {
+	for _, property := range status.Properties {
+		if property.Name != name {
+			continue
+		}
+		if property.Reported != "" {
+			return property.Reported
+		}
+		if property.Desired != "" {
+			return property.Desired
+		}
 	}
+	return fallback
 }
@@ -0,0 +1,50 @@
+package physical
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+	"github.com/rancher/octopus/adaptors/ble/pkg/physical/transport"
+)
+
+// matchDiscovery reports whether a discovered peripheral satisfies the
+// spec's name/address, RSSI threshold and allow/block list rules.
+func matchDiscovery(spec v1alpha1.BluetoothDeviceSpec, p transport.Peripheral, a transport.Advertisement) bool {
+	var protocol = spec.Protocol
+
+	if protocol.Name != "" && a.LocalName != protocol.Name {
+		return false
+	}
+	if protocol.MacAddress != "" && !strings.EqualFold(p.ID(), protocol.MacAddress) {
+		return false
+	}
+	if protocol.MinRSSI != 0 && a.RSSI < protocol.MinRSSI {
+		return false
+	}
+	if len(protocol.Blocklist) > 0 && matchesAny(protocol.Blocklist, p.ID(), a.LocalName) {
+		return false
+	}
+	if len(protocol.Allowlist) > 0 && !matchesAny(protocol.Allowlist, p.ID(), a.LocalName) {
+		return false
+	}
+	return true
+}
+
+// matchesAny reports whether mac or name satisfies any of patterns. A
+// pattern matches mac as a case-insensitive prefix, or name as a glob (e.g.
+// "Sensor-*").
+func matchesAny(patterns []string, mac, name string) bool {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if strings.HasPrefix(strings.ToUpper(mac), strings.ToUpper(pattern)) {
+			return true
+		}
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
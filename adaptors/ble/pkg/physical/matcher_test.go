@@ -0,0 +1,57 @@
+package physical
+
+import "testing"
+
+func TestMatchesAny(t *testing.T) {
+	var cases = []struct {
+		name     string
+		patterns []string
+		mac      string
+		local    string
+		want     bool
+	}{
+		{
+			name:     "mac prefix match",
+			patterns: []string{"AA:BB:CC"},
+			mac:      "aa:bb:cc:dd:ee:ff",
+			local:    "unrelated",
+			want:     true,
+		},
+		{
+			name:     "mac prefix mismatch",
+			patterns: []string{"AA:BB:CC"},
+			mac:      "11:22:33:dd:ee:ff",
+			local:    "unrelated",
+			want:     false,
+		},
+		{
+			name:     "name glob match",
+			patterns: []string{"Sensor-*"},
+			mac:      "00:00:00:00:00:00",
+			local:    "Sensor-42",
+			want:     true,
+		},
+		{
+			name:     "name glob mismatch",
+			patterns: []string{"Sensor-*"},
+			mac:      "00:00:00:00:00:00",
+			local:    "Beacon-42",
+			want:     false,
+		},
+		{
+			name:     "no patterns",
+			patterns: nil,
+			mac:      "00:00:00:00:00:00",
+			local:    "anything",
+			want:     false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := matchesAny(c.patterns, c.mac, c.local); got != c.want {
+				t.Errorf("matchesAny(%v, %q, %q) = %v, want %v", c.patterns, c.mac, c.local, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package physical
+
+import (
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+	"github.com/rancher/octopus/adaptors/ble/pkg/physical/transport"
+)
+
+// peripheralModeProtocol is the BluetoothDeviceSpec.Protocol.Mode value that
+// flips a device into GATT-server mode instead of the default central mode.
+const peripheralModeProtocol = "Peripheral"
+
+// peripheralServerEntry is a single BluetoothDevice advertised by a
+// PeripheralServer.
+type peripheralServerEntry struct {
+	name    types.NamespacedName
+	spec    v1alpha1.BluetoothDeviceSpec
+	status  v1alpha1.BluetoothDeviceStatus
+	handler DataHandler
+}
+
+// PeripheralServer drives a shared transport.Transport in peripheral/server
+// mode. For every registered BluetoothDevice it publishes the properties in
+// its spec as local GATT services and characteristics (UUIDs and
+// permissions from Properties, initial values from DefaultValue),
+// advertises the configured local name, and reports writes from remote
+// centrals back through DataHandler the same way the central role reports
+// a sync.
+type PeripheralServer struct {
+	mu        sync.Mutex
+	log       logr.Logger
+	transport transport.Transport
+	started   bool
+	entries   map[types.NamespacedName]*peripheralServerEntry
+}
+
+// NewPeripheralServer creates a PeripheralServer bound to a single shared
+// Transport.
+func NewPeripheralServer(log logr.Logger, t transport.Transport) *PeripheralServer {
+	return &PeripheralServer{
+		log:       log,
+		transport: t,
+		entries:   make(map[types.NamespacedName]*peripheralServerEntry),
+	}
+}
+
+// Register publishes spec's properties as local GATT services and starts
+// advertising them, starting the shared adapter the first time it is
+// called.
+func (ps *PeripheralServer) Register(name types.NamespacedName, spec v1alpha1.BluetoothDeviceSpec, status v1alpha1.BluetoothDeviceStatus,
+	handler DataHandler, _ Parameters) {
+	var entry = &peripheralServerEntry{name: name, spec: spec, status: status, handler: handler}
+
+	ps.mu.Lock()
+	ps.entries[name] = entry
+	var alreadyStarted = ps.started
+	ps.started = true
+	ps.mu.Unlock()
+
+	for _, property := range spec.Properties {
+		var svc = ps.buildService(entry, property)
+		if err := ps.transport.AddService(svc); err != nil {
+			ps.log.Error(err, "Failed to add GATT service")
+		}
+	}
+
+	if !alreadyStarted {
+		if err := ps.transport.Init(ps.onStateChanged); err != nil {
+			ps.log.Error(err, "Failed to initialize transport")
+		}
+		return
+	}
+	ps.advertise()
+}
+
+// Deregister removes name and re-advertises the remaining entries. The
+// services that Register published for name stay published, and when name
+// was the last registered entry the current advertisement is left running.
+// Other registered peripherals, and the shared adapter itself, are left
+// running.
+func (ps *PeripheralServer) Deregister(name types.NamespacedName) {
+	ps.mu.Lock()
+	delete(ps.entries, name)
+	ps.mu.Unlock()
+	ps.advertise()
+}
+
+func (ps *PeripheralServer) onStateChanged(t transport.Transport, poweredOn bool) {
+	ps.log.Info("Bluetooth adapter powered on", poweredOn)
+	if poweredOn {
+		ps.advertise()
+	}
+}
+
+// advertise (re)starts advertising under the local name of one registered
+// entry, for every currently registered service UUID. Entries are kept in a
+// map, so which entry's name is used when several are registered is not
+// defined. With no entry registered it does nothing.
+func (ps *PeripheralServer) advertise() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	var name string
+	var uuids []string
+	for _, entry := range ps.entries {
+		if name == "" {
+			name = entry.spec.Protocol.Name
+		}
+		for _, property := range entry.spec.Properties {
+			uuids = append(uuids, property.Visitor.CharacteristicUUID)
+		}
+	}
+	if len(ps.entries) == 0 {
+		return
+	}
+	if err := ps.transport.Advertise(name, uuids); err != nil {
+		ps.log.Error(err, "Failed to advertise")
+	}
+}
+
+// buildService turns a single DeviceProperty into a local GATT service
+// exposing one characteristic, with read/write handlers derived from
+// AccessMode.
+func (ps *PeripheralServer) buildService(entry *peripheralServerEntry, property v1alpha1.DeviceProperty) transport.LocalService {
+	var svc = transport.LocalService{UUID: property.Visitor.CharacteristicUUID}
+
+	if property.AccessMode == v1alpha1.ReadOnly || property.AccessMode == v1alpha1.ReadWrite {
+		svc.OnRead = func() ([]byte, error) {
+			ps.mu.Lock()
+			var value = statusPropertyValue(entry.status, property.Name, property.Visitor.DefaultValue)
+			ps.mu.Unlock()
+
+			codec, err := newCodec(property.Visitor)
+			if err != nil {
+				return nil, err
+			}
+			return codec.Encode(value)
+		}
+	}
+
+	if property.AccessMode == v1alpha1.ReadWrite {
+		svc.OnWrite = func(data []byte) error {
+			codec, err := newCodec(property.Visitor)
+			if err != nil {
+				return err
+			}
+			value, err := codec.Decode(data)
+			if err != nil {
+				return err
+			}
+
+			ps.mu.Lock()
+			upsertStatusProperty(&entry.status, property.Name, value, value)
+			var status = entry.status
+			ps.mu.Unlock()
+
+			entry.handler(entry.name, status)
+			return nil
+		}
+	}
+
+	return svc
+}
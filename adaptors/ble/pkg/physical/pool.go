@@ -0,0 +1,303 @@
+package physical
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+	"github.com/rancher/octopus/adaptors/ble/pkg/physical/transport"
+)
+
+// PeripheralState tracks where a peripheral registered with a Pool is in its
+// connection lifecycle.
+type PeripheralState string
+
+const (
+	PeripheralDiscovered   PeripheralState = "Discovered"
+	PeripheralConnecting   PeripheralState = "Connecting"
+	PeripheralConnected    PeripheralState = "Connected"
+	PeripheralDisconnected PeripheralState = "Disconnected"
+	// PeripheralScanned marks a ScanOnly entry whose advertisement has been
+	// recorded, so further advertisements from the same peripheral (BLE
+	// devices advertise several times a second) are not treated as pending
+	// and re-matched until run() starts its next cycle and sets the entry
+	// back to PeripheralDiscovered.
+	PeripheralScanned PeripheralState = "Scanned"
+)
+
+// peripheralEntry is a single BluetoothDevice registered with a Pool.
+type peripheralEntry struct {
+	name    types.NamespacedName
+	handler DataHandler
+	param   Parameters
+
+	controller   *Controller
+	peripheralID string
+	stop         chan struct{}
+
+	// state, connectFailed and controller.done are read and written from
+	// both run() and the transport callback goroutines, so every access must
+	// hold Pool.mu.
+	state PeripheralState
+	// connectFailed records whether the most recent connect attempt ended in
+	// a transport-reported error rather than a real disconnect after a
+	// successful session, so run() only resets the reconnect backoff on the
+	// latter.
+	connectFailed bool
+}
+
+// Pool multiplexes a single shared transport.Transport across many
+// registered BluetoothDevice specs, keyed by name, so one Octopus node can
+// serve dozens of peripherals from a single BLE radio instead of running a
+// scan loop per device.
+type Pool struct {
+	mu        sync.Mutex
+	log       logr.Logger
+	transport transport.Transport
+	started   bool
+	entries   map[types.NamespacedName]*peripheralEntry
+}
+
+// NewPool creates a Pool bound to a single shared Transport.
+func NewPool(log logr.Logger, t transport.Transport) *Pool {
+	return &Pool{
+		log:       log,
+		transport: t,
+		entries:   make(map[types.NamespacedName]*peripheralEntry),
+	}
+}
+
+// Register adds spec to the pool under name and starts its connection loop,
+// starting the shared scanner the first time the pool is used. Registering
+// an already-known name replaces its spec and restarts its loop.
+func (pl *Pool) Register(name types.NamespacedName, spec v1alpha1.BluetoothDeviceSpec, status v1alpha1.BluetoothDeviceStatus,
+	handler DataHandler, param Parameters) {
+	pl.mu.Lock()
+	if existing, ok := pl.entries[name]; ok {
+		close(existing.stop)
+	}
+	var stop = make(chan struct{})
+	var entry = &peripheralEntry{
+		name:    name,
+		handler: handler,
+		param:   param,
+		state:   PeripheralDiscovered,
+		stop:    stop,
+		controller: &Controller{
+			spec:   spec,
+			status: status,
+			log:    pl.log,
+			stop:   stop,
+		},
+	}
+	pl.entries[name] = entry
+	var alreadyStarted = pl.started
+	pl.started = true
+	pl.mu.Unlock()
+
+	go pl.run(entry)
+
+	if !alreadyStarted {
+		pl.transport.Handle(pl.onPeripheralDiscovered, pl.onPeripheralConnected, pl.onPeripheralDisconnected)
+		if err := pl.transport.Init(pl.onStateChanged); err != nil {
+			pl.log.Error(err, "Failed to initialize transport")
+		}
+	}
+}
+
+// Deregister removes name from the pool without tearing down the shared
+// adapter or affecting any other registered peripheral.
+func (pl *Pool) Deregister(name types.NamespacedName) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if entry, ok := pl.entries[name]; ok {
+		close(entry.stop)
+		delete(pl.entries, name)
+	}
+}
+
+// run drives one entry's connect/reconnect lifecycle, independent of the
+// shared scanner and of every other registered entry.
+func (pl *Pool) run(entry *peripheralEntry) {
+	var ticker = time.NewTicker(entry.param.SyncInterval * time.Second)
+	defer ticker.Stop()
+
+	var backoff = newReconnectBackoff(entry.param.ReconnectPolicy, entry.param.ReconnectBackoffCeiling)
+	for {
+		pl.mu.Lock()
+		entry.controller.done = make(chan struct{})
+		entry.state = PeripheralDiscovered
+		var doneCh = entry.controller.done
+		pl.mu.Unlock()
+
+		<-doneCh
+
+		pl.mu.Lock()
+		var succeeded = !entry.connectFailed
+		pl.mu.Unlock()
+		if succeeded {
+			backoff.reset()
+		}
+		entry.handler(entry.name, entry.controller.status)
+
+		select {
+		case <-entry.stop:
+			return
+		case <-ticker.C:
+			entry.controller.syncDue = true
+			continue
+		default:
+		}
+
+		if !entry.param.ReconnectPolicy.autoReconnect() {
+			select {
+			case <-entry.stop:
+				return
+			case <-ticker.C:
+				entry.controller.syncDue = true
+			}
+			continue
+		}
+
+		entry.controller.syncDue = false
+		var wait = backoff.next()
+		pl.log.Info("Peripheral disconnected, reconnecting after", wait.String())
+		select {
+		case <-entry.stop:
+			return
+		case <-time.After(wait):
+		case <-ticker.C:
+			entry.controller.syncDue = true
+		}
+	}
+}
+
+func (pl *Pool) onStateChanged(t transport.Transport, poweredOn bool) {
+	pl.log.Info("Bluetooth adapter powered on", poweredOn)
+	if !poweredOn {
+		_ = pl.transport.StopScan()
+		return
+	}
+	pl.log.Info("Scanning...")
+	if err := pl.transport.Scan(); err != nil {
+		pl.log.Error(err, "Failed to start scanning")
+	}
+}
+
+// onPeripheralDiscovered fans a discovery event out to every entry still
+// waiting on a peripheral, since any of them might be the one advertising.
+func (pl *Pool) onPeripheralDiscovered(p transport.Peripheral, a transport.Advertisement) {
+	pl.mu.Lock()
+	var pending = make([]*peripheralEntry, 0, len(pl.entries))
+	for _, entry := range pl.entries {
+		if entry.state == PeripheralDiscovered {
+			pending = append(pending, entry)
+		}
+	}
+	pl.mu.Unlock()
+
+	var matched bool
+	for _, entry := range pending {
+		// held across the match (and the ScanOnly branch's close of
+		// controller.done) so it can't race run() recreating that channel
+		pl.mu.Lock()
+		var ok = entry.controller.onPeripheralDiscovered(p, a)
+		if !ok {
+			pl.mu.Unlock()
+			continue
+		}
+		entry.peripheralID = p.ID()
+		matched = true
+		var scanOnly = entry.controller.spec.Protocol.ScanOnly
+		if scanOnly {
+			entry.state = PeripheralScanned
+		} else {
+			entry.state = PeripheralConnecting
+		}
+		pl.mu.Unlock()
+
+		if scanOnly {
+			continue
+		}
+		if err := pl.transport.Connect(p); err != nil {
+			pl.log.Error(err, "Failed to connect to peripheral")
+		}
+	}
+	if matched && !pl.hasPending() {
+		_ = pl.transport.StopScan()
+	}
+}
+
+func (pl *Pool) hasPending() bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	for _, entry := range pl.entries {
+		if entry.state == PeripheralDiscovered {
+			return true
+		}
+	}
+	return false
+}
+
+func (pl *Pool) onPeripheralConnected(p transport.Peripheral, err error) {
+	var entry = pl.byPeripheralID(p.ID())
+	if entry == nil {
+		return
+	}
+
+	if err != nil {
+		pl.log.Error(err, "Failed to connect to peripheral")
+		pl.mu.Lock()
+		entry.state = PeripheralDisconnected
+		entry.connectFailed = true
+		if entry.controller.done != nil {
+			close(entry.controller.done)
+		}
+		pl.mu.Unlock()
+		return
+	}
+
+	pl.mu.Lock()
+	entry.state = PeripheralConnected
+	entry.connectFailed = false
+	pl.mu.Unlock()
+
+	// onPeripheralConnected holds the session open until deregistration or
+	// disconnect, so it must run in its own goroutine: this callback fires
+	// synchronously on the transport's connect path (e.g. tinygodriver's
+	// Connect is called inline from the scan-result callback), and blocking
+	// it here would wedge discovery for every other registered peripheral.
+	go entry.controller.onPeripheralConnected(p, nil)
+}
+
+func (pl *Pool) onPeripheralDisconnected(p transport.Peripheral, err error) {
+	var entry = pl.byPeripheralID(p.ID())
+	if entry == nil {
+		return
+	}
+	pl.mu.Lock()
+	entry.state = PeripheralDisconnected
+	entry.controller.onPeriphDisconnected(p, err)
+	pl.mu.Unlock()
+
+	// resume scanning so other registered entries, or this one on reconnect,
+	// can still be discovered
+	if err := pl.transport.Scan(); err != nil {
+		pl.log.Error(err, "Failed to resume scanning")
+	}
+}
+
+func (pl *Pool) byPeripheralID(id string) *peripheralEntry {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	for _, entry := range pl.entries {
+		if strings.EqualFold(entry.peripheralID, id) {
+			return entry
+		}
+	}
+	return nil
+}
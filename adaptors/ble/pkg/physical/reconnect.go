@@ -0,0 +1,67 @@
+package physical
+
+import "time"
+
+// ReconnectPolicy controls whether and how the device reconnects to a
+// peripheral once it has disconnected, instead of waiting for the next
+// sync interval tick.
+type ReconnectPolicy string
+
+const (
+	// ReconnectNever disables auto-reconnect; the device falls back to the
+	// previous behaviour of only retrying on the next sync interval tick.
+	ReconnectNever ReconnectPolicy = "Never"
+	// ReconnectAlways reconnects immediately, backing off exponentially
+	// between attempts with no upper bound.
+	ReconnectAlways ReconnectPolicy = "Always"
+	// ReconnectBackoffMax reconnects immediately, backing off exponentially
+	// between attempts up to a configurable ceiling.
+	ReconnectBackoffMax ReconnectPolicy = "BackoffMax"
+)
+
+const (
+	defaultReconnectBackoffBase = 1 * time.Second
+	defaultReconnectBackoffMax  = 60 * time.Second
+)
+
+// autoReconnect reports whether p should trigger Pool.run's immediate
+// reconnect-with-backoff path instead of waiting for the next sync interval
+// tick. Reconnecting is opt-in: an empty/unset policy behaves like
+// ReconnectNever.
+func (p ReconnectPolicy) autoReconnect() bool {
+	return p == ReconnectAlways || p == ReconnectBackoffMax
+}
+
+// reconnectBackoff tracks the wait duration between successive reconnect
+// attempts, doubling it each time up to an optional ceiling.
+type reconnectBackoff struct {
+	policy  ReconnectPolicy
+	ceiling time.Duration
+	current time.Duration
+}
+
+func newReconnectBackoff(policy ReconnectPolicy, ceiling time.Duration) *reconnectBackoff {
+	if ceiling <= 0 {
+		ceiling = defaultReconnectBackoffMax
+	}
+	return &reconnectBackoff{policy: policy, ceiling: ceiling}
+}
+
+// next returns the duration to wait before the next reconnect attempt and
+// advances the backoff state.
+func (b *reconnectBackoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = defaultReconnectBackoffBase
+		return b.current
+	}
+	b.current *= 2
+	if b.policy == ReconnectBackoffMax && b.current > b.ceiling {
+		b.current = b.ceiling
+	}
+	return b.current
+}
+
+// reset clears the backoff state after a successful connection.
+func (b *reconnectBackoff) reset() {
+	b.current = 0
+}
@@ -0,0 +1,20 @@
+package physical
+
+import (
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rancher/octopus/adaptors/ble/api/v1alpha1"
+)
+
+// Role drives a shared transport.Transport on behalf of every
+// BluetoothDevice registered with it. Pool implements it for the central
+// role (scan,
+// connect, read/write/notify a remote peripheral); PeripheralServer
+// implements it for the peripheral role (advertise local services and serve
+// reads/writes from remote centrals). Both report status through
+// DataHandler; only Pool uses Parameters, PeripheralServer ignores them.
+type Role interface {
+	Register(name types.NamespacedName, spec v1alpha1.BluetoothDeviceSpec, status v1alpha1.BluetoothDeviceStatus,
+		handler DataHandler, param Parameters)
+	Deregister(name types.NamespacedName)
+}
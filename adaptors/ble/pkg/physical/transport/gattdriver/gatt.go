@@ -0,0 +1,170 @@
+// Package gattdriver implements transport.Transport on top of
+// github.com/bettercap/gatt, the original (Linux/HCI only) backend of the
+// ble adaptor.
+package gattdriver
+
+import (
+	"github.com/bettercap/gatt"
+
+	"github.com/rancher/octopus/adaptors/ble/pkg/physical/transport"
+)
+
+// Driver adapts a gatt.Device to transport.Transport.
+type Driver struct {
+	device gatt.Device
+}
+
+// New wraps an already-constructed gatt.Device.
+func New(device gatt.Device) *Driver {
+	return &Driver{device: device}
+}
+
+func (d *Driver) Init(onStateChanged func(t transport.Transport, poweredOn bool)) error {
+	d.device.Init(func(_ gatt.Device, s gatt.State) {
+		onStateChanged(d, s == gatt.StatePoweredOn)
+	})
+	return nil
+}
+
+func (d *Driver) Handle(onDiscovered func(transport.Peripheral, transport.Advertisement), onConnected func(transport.Peripheral, error), onDisconnected func(transport.Peripheral, error)) {
+	d.device.Handle(
+		gatt.PeripheralDiscovered(func(p gatt.Peripheral, a *gatt.Advertisement, rssi int) {
+			onDiscovered(peripheral{p}, toAdvertisement(a, rssi))
+		}),
+		gatt.PeripheralConnected(func(p gatt.Peripheral, err error) {
+			onConnected(peripheral{p}, err)
+		}),
+		gatt.PeripheralDisconnected(func(p gatt.Peripheral, err error) {
+			onDisconnected(peripheral{p}, err)
+		}),
+	)
+}
+
+func (d *Driver) Scan() error {
+	d.device.Scan([]gatt.UUID{}, false)
+	return nil
+}
+
+func (d *Driver) StopScan() error {
+	d.device.StopScanning()
+	return nil
+}
+
+func (d *Driver) Connect(p transport.Peripheral) error {
+	d.device.Connect(p.(peripheral).p)
+	return nil
+}
+
+func (d *Driver) AddService(svc transport.LocalService) error {
+	var uuid = gatt.MustParseUUID(svc.UUID)
+	var gs = gatt.NewService(uuid)
+	var ch = gs.AddCharacteristic(uuid)
+
+	if svc.OnRead != nil {
+		ch.HandleReadFunc(func(rsp gatt.ResponseWriter, req *gatt.ReadRequest) {
+			b, err := svc.OnRead()
+			if err != nil {
+				return
+			}
+			_, _ = rsp.Write(b)
+		})
+	}
+	if svc.OnWrite != nil {
+		ch.HandleWriteFunc(func(r gatt.Request, data []byte) byte {
+			if err := svc.OnWrite(data); err != nil {
+				return gatt.StatusUnexpectedError
+			}
+			return gatt.StatusSuccess
+		})
+	}
+
+	return d.device.AddService(gs)
+}
+
+func (d *Driver) Advertise(name string, serviceUUIDs []string) error {
+	var uuids = make([]gatt.UUID, 0, len(serviceUUIDs))
+	for _, u := range serviceUUIDs {
+		uuids = append(uuids, gatt.MustParseUUID(u))
+	}
+	return d.device.AdvertiseNameAndServices(name, uuids)
+}
+
+// peripheral adapts a gatt.Peripheral to transport.Peripheral.
+type peripheral struct {
+	p gatt.Peripheral
+}
+
+func (p peripheral) ID() string    { return p.p.ID() }
+func (p peripheral) Name() string  { return p.p.Name() }
+func (p peripheral) ReadRSSI() int { return p.p.ReadRSSI() }
+
+func (p peripheral) Disconnect() error {
+	p.p.Device().CancelConnection(p.p)
+	return nil
+}
+
+func (p peripheral) DiscoverCharacteristics() ([]transport.Characteristic, error) {
+	services, err := p.p.DiscoverServices(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []transport.Characteristic
+	for _, svc := range services {
+		chars, err := p.p.DiscoverCharacteristics(nil, svc)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range chars {
+			out = append(out, characteristic{ch})
+		}
+	}
+	return out, nil
+}
+
+func (p peripheral) ReadCharacteristic(ch transport.Characteristic) ([]byte, error) {
+	return p.p.ReadCharacteristic(ch.(characteristic).ch)
+}
+
+func (p peripheral) WriteCharacteristic(ch transport.Characteristic, data []byte, noResponse bool) error {
+	return p.p.WriteCharacteristic(ch.(characteristic).ch, data, noResponse)
+}
+
+func (p peripheral) SetNotifyValue(ch transport.Characteristic, f func(transport.Characteristic, []byte, error)) error {
+	var gc = ch.(characteristic)
+	if _, err := p.p.DiscoverDescriptors(nil, gc.ch); err != nil {
+		return err
+	}
+	return p.p.SetNotifyValue(gc.ch, func(_ *gatt.Characteristic, b []byte, err error) {
+		f(gc, b, err)
+	})
+}
+
+// characteristic adapts a *gatt.Characteristic to transport.Characteristic.
+type characteristic struct {
+	ch *gatt.Characteristic
+}
+
+func (c characteristic) UUID() string { return c.ch.UUID().String() }
+func (c characteristic) Notifiable() bool {
+	return (c.ch.Properties() & (gatt.CharNotify | gatt.CharIndicate)) != 0
+}
+
+func toAdvertisement(a *gatt.Advertisement, rssi int) transport.Advertisement {
+	var out = transport.Advertisement{
+		LocalName: a.LocalName,
+		RSSI:      rssi,
+	}
+	if a.TxPowerLevel != 0 {
+		var level = a.TxPowerLevel
+		out.TxPowerLevel = &level
+	}
+	for _, uuid := range a.Services {
+		out.ServiceUUIDs = append(out.ServiceUUIDs, uuid.String())
+	}
+	for _, sd := range a.ServiceData {
+		out.ServiceData = append(out.ServiceData, transport.ServiceData{UUID: sd.UUID.String(), Data: sd.Data})
+	}
+	out.ManufacturerData = a.ManufacturerData
+	return out
+}
@@ -0,0 +1,220 @@
+// Package tinygodriver implements transport.Transport on top of
+// tinygo.org/x/bluetooth, which (unlike github.com/bettercap/gatt) supports
+// Linux (BlueZ over D-Bus), macOS (CoreBluetooth), Windows (WinRT) and
+// embedded HCI/UART targets, giving the ble adaptor a path off the
+// unmaintained bettercap/gatt dependency.
+package tinygodriver
+
+import (
+	"fmt"
+
+	"tinygo.org/x/bluetooth"
+
+	"github.com/rancher/octopus/adaptors/ble/pkg/physical/transport"
+)
+
+// Driver adapts a *bluetooth.Adapter to transport.Transport.
+type Driver struct {
+	adapter      *bluetooth.Adapter
+	onDiscovered func(transport.Peripheral, transport.Advertisement)
+	onConnected  func(transport.Peripheral, error)
+}
+
+// New wraps adapter, typically bluetooth.DefaultAdapter.
+func New(adapter *bluetooth.Adapter) *Driver {
+	return &Driver{adapter: adapter}
+}
+
+func (d *Driver) Init(onStateChanged func(t transport.Transport, poweredOn bool)) error {
+	if err := d.adapter.Enable(); err != nil {
+		return fmt.Errorf("failed to enable adapter: %w", err)
+	}
+	// onStateChanged typically turns around and calls Scan, which this
+	// adapter blocks on until StopScan is called; run it in its own
+	// goroutine so Init itself still returns promptly.
+	go onStateChanged(d, true)
+	return nil
+}
+
+func (d *Driver) Handle(onDiscovered func(transport.Peripheral, transport.Advertisement), onConnected func(transport.Peripheral, error), onDisconnected func(transport.Peripheral, error)) {
+	d.onDiscovered = onDiscovered
+	d.onConnected = onConnected
+
+	d.adapter.SetConnectHandler(func(device bluetooth.Device, connected bool) {
+		if connected {
+			return
+		}
+		onDisconnected(&peripheral{device: device}, nil)
+	})
+}
+
+func (d *Driver) Scan() error {
+	return d.adapter.Scan(func(adapter *bluetooth.Adapter, result bluetooth.ScanResult) {
+		if d.onDiscovered == nil {
+			return
+		}
+		d.onDiscovered(&scanned{adapter: adapter, result: result}, toAdvertisement(result))
+	})
+}
+
+func (d *Driver) StopScan() error {
+	return d.adapter.StopScan()
+}
+
+func (d *Driver) Connect(p transport.Peripheral) error {
+	s, ok := p.(*scanned)
+	if !ok {
+		return fmt.Errorf("peripheral %s is not a scan result", p.ID())
+	}
+
+	device, err := d.adapter.Connect(s.result.Address, bluetooth.ConnectionParams{})
+	if err != nil {
+		if d.onConnected != nil {
+			d.onConnected(p, err)
+		}
+		return err
+	}
+	if d.onConnected != nil {
+		d.onConnected(&peripheral{device: device}, nil)
+	}
+	return nil
+}
+
+func (d *Driver) AddService(svc transport.LocalService) error {
+	uuid, err := bluetooth.ParseUUID(svc.UUID)
+	if err != nil {
+		return err
+	}
+
+	var characteristic bluetooth.CharacteristicConfig
+	characteristic.UUID = uuid
+	if svc.OnRead != nil {
+		characteristic.Flags |= bluetooth.CharacteristicReadPermission
+		characteristic.Handler = func(client bluetooth.Connection, offset int, value []byte) []byte {
+			b, err := svc.OnRead()
+			if err != nil {
+				return nil
+			}
+			return b
+		}
+	}
+	if svc.OnWrite != nil {
+		characteristic.Flags |= bluetooth.CharacteristicWritePermission
+		characteristic.WriteEvent = func(client bluetooth.Connection, offset int, value []byte) {
+			_ = svc.OnWrite(value)
+		}
+	}
+
+	return d.adapter.AddService(&bluetooth.Service{
+		UUID:            uuid,
+		Characteristics: []bluetooth.CharacteristicConfig{characteristic},
+	})
+}
+
+func (d *Driver) Advertise(name string, serviceUUIDs []string) error {
+	var uuids = make([]bluetooth.UUID, 0, len(serviceUUIDs))
+	for _, u := range serviceUUIDs {
+		parsed, err := bluetooth.ParseUUID(u)
+		if err != nil {
+			return err
+		}
+		uuids = append(uuids, parsed)
+	}
+
+	var adv = d.adapter.DefaultAdvertisement()
+	if err := adv.Configure(bluetooth.AdvertisementOptions{LocalName: name, ServiceUUIDs: uuids}); err != nil {
+		return err
+	}
+	return adv.Start()
+}
+
+// scanned adapts an in-progress bluetooth.ScanResult (not yet connected) to
+// transport.Peripheral, so Connect has something to dial.
+type scanned struct {
+	adapter *bluetooth.Adapter
+	result  bluetooth.ScanResult
+}
+
+func (s *scanned) ID() string    { return s.result.Address.String() }
+func (s *scanned) Name() string  { return s.result.LocalName() }
+func (s *scanned) ReadRSSI() int { return int(s.result.RSSI) }
+
+func (s *scanned) DiscoverCharacteristics() ([]transport.Characteristic, error) {
+	return nil, fmt.Errorf("peripheral %s is not connected", s.ID())
+}
+func (s *scanned) ReadCharacteristic(transport.Characteristic) ([]byte, error) {
+	return nil, fmt.Errorf("peripheral %s is not connected", s.ID())
+}
+func (s *scanned) WriteCharacteristic(transport.Characteristic, []byte, bool) error {
+	return fmt.Errorf("peripheral %s is not connected", s.ID())
+}
+func (s *scanned) SetNotifyValue(transport.Characteristic, func(transport.Characteristic, []byte, error)) error {
+	return fmt.Errorf("peripheral %s is not connected", s.ID())
+}
+func (s *scanned) Disconnect() error { return nil }
+
+// peripheral adapts a connected bluetooth.Device to transport.Peripheral.
+type peripheral struct {
+	device bluetooth.Device
+}
+
+func (p *peripheral) ID() string        { return p.device.Address.String() }
+func (p *peripheral) Name() string      { return "" }
+func (p *peripheral) ReadRSSI() int     { return 0 }
+func (p *peripheral) Disconnect() error { return p.device.Disconnect() }
+
+func (p *peripheral) DiscoverCharacteristics() ([]transport.Characteristic, error) {
+	services, err := p.device.DiscoverServices(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []transport.Characteristic
+	for _, svc := range services {
+		chars, err := svc.DiscoverCharacteristics(nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range chars {
+			out = append(out, characteristic{ch})
+		}
+	}
+	return out, nil
+}
+
+func (p *peripheral) ReadCharacteristic(ch transport.Characteristic) ([]byte, error) {
+	var buf = make([]byte, 512)
+	n, err := ch.(characteristic).ch.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (p *peripheral) WriteCharacteristic(ch transport.Characteristic, data []byte, _ bool) error {
+	_, err := ch.(characteristic).ch.WriteWithoutResponse(data)
+	return err
+}
+
+func (p *peripheral) SetNotifyValue(ch transport.Characteristic, f func(transport.Characteristic, []byte, error)) error {
+	var gc = ch.(characteristic)
+	return gc.ch.EnableNotifications(func(b []byte) {
+		f(gc, b, nil)
+	})
+}
+
+// characteristic adapts a bluetooth.DeviceCharacteristic to
+// transport.Characteristic.
+type characteristic struct {
+	ch bluetooth.DeviceCharacteristic
+}
+
+func (c characteristic) UUID() string     { return c.ch.UUID().String() }
+func (c characteristic) Notifiable() bool { return true }
+
+func toAdvertisement(result bluetooth.ScanResult) transport.Advertisement {
+	return transport.Advertisement{
+		LocalName: result.LocalName(),
+		RSSI:      int(result.RSSI),
+	}
+}
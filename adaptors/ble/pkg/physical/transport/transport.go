@@ -0,0 +1,77 @@
+// Package transport abstracts the BLE radio so the ble adaptor is not
+// pinned to a single GATT library. physical.Pool and physical.PeripheralServer
+// drive a Transport instead of a concrete github.com/bettercap/gatt.Device;
+// transport/gattdriver wraps that library for Linux/HCI, and
+// transport/tinygodriver wraps tinygo.org/x/bluetooth for Linux (BlueZ),
+// macOS (CoreBluetooth), Windows (WinRT) and embedded HCI/UART targets.
+package transport
+
+// Advertisement is the subset of an advertising packet adaptors care about,
+// independent of the underlying BLE stack.
+type Advertisement struct {
+	LocalName        string
+	RSSI             int
+	TxPowerLevel     *int
+	ManufacturerData []byte
+	ServiceUUIDs     []string
+	ServiceData      []ServiceData
+}
+
+// ServiceData is a single service-data element of an Advertisement.
+type ServiceData struct {
+	UUID string
+	Data []byte
+}
+
+// Characteristic is a single GATT characteristic discovered on a connected
+// Peripheral.
+type Characteristic interface {
+	UUID() string
+	Notifiable() bool
+}
+
+// Peripheral is a remote device reachable once Transport.Connect succeeds.
+type Peripheral interface {
+	ID() string
+	Name() string
+	ReadRSSI() int
+	DiscoverCharacteristics() ([]Characteristic, error)
+	ReadCharacteristic(ch Characteristic) ([]byte, error)
+	WriteCharacteristic(ch Characteristic, data []byte, noResponse bool) error
+	SetNotifyValue(ch Characteristic, f func(ch Characteristic, b []byte, err error)) error
+	// Disconnect tears down the connection opened by Transport.Connect.
+	Disconnect() error
+}
+
+// LocalService describes a single GATT service/characteristic pair to
+// publish while acting as a peripheral.
+type LocalService struct {
+	UUID       string
+	Notifiable bool
+	OnRead     func() ([]byte, error)
+	OnWrite    func(data []byte) error
+}
+
+// Transport drives a single BLE adapter, either as a central that scans for
+// and connects to remote peripherals, or as a peripheral that advertises
+// local services for remote centrals to connect to.
+type Transport interface {
+	// Init brings the adapter up, invoking onStateChanged whenever its
+	// powered state changes.
+	Init(onStateChanged func(t Transport, poweredOn bool)) error
+
+	// Handle registers the callbacks driven by Scan/Connect: onDiscovered
+	// for every advertisement seen, onConnected/onDisconnected for a
+	// peripheral this adapter connected to.
+	Handle(onDiscovered func(Peripheral, Advertisement), onConnected func(Peripheral, error), onDisconnected func(Peripheral, error))
+
+	Scan() error
+	StopScan() error
+	Connect(p Peripheral) error
+
+	// AddService publishes a local service while acting as a peripheral.
+	AddService(svc LocalService) error
+	// Advertise starts, or refreshes, advertising under name with the given
+	// service UUIDs.
+	Advertise(name string, serviceUUIDs []string) error
+}